@@ -18,16 +18,34 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"path"
+	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"cuelang.org/go/cue/cuecontext"
 	"github.com/fluxcd/pkg/ssa"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apiextensionsclientset "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 	"sigs.k8s.io/yaml"
 
 	"github.com/stefanprodan/timoni/internal/engine"
@@ -38,20 +56,62 @@ var importCrdCmd = &cobra.Command{
 	Short: "Generate CUE definitions from Kubernetes CRDs",
 	Example: `  # generate CUE definitions from a local YAML file
   timoni mod import crd -f crds.yaml
+
+  # generate CUE definitions from multiple files and directories
+  timoni mod import crd -f crds/ -f extra-crd.yaml
+
+  # generate CUE definitions from a kustomize overlay
+  timoni mod import crd --kustomize ./config/crd
+
+  # generate CUE definitions from a CRD YAML served over HTTPS
+  timoni mod import crd --url https://example.com/crds.yaml --sha256 <checksum>
+
+  # generate CUE definitions from the CRDs installed on the current cluster
+  timoni mod import crd --from-cluster --group cert-manager.io
 `,
 	RunE: runImportCrdCmd,
 }
 
 type importCrdFlags struct {
-	modRoot string
-	crdFile string
+	modRoot     string
+	crdFiles    []string
+	kustomize   string
+	crdURL      string
+	crdSHA256   string
+	fromCluster bool
+	crdGroup    string
+	kubeconfig  string
+	kubeContext string
+	versions    []string
+	storageOnly bool
+	watch       bool
 }
 
 var importCrdArgs importCrdFlags
 
 func init() {
-	importCrdCmd.Flags().StringVarP(&importCrdArgs.crdFile, "file", "f", "",
-		"The path to Kubernetes CRD YAML.")
+	importCrdCmd.Flags().StringArrayVarP(&importCrdArgs.crdFiles, "file", "f", nil,
+		"The path to a Kubernetes CRD YAML, a directory of YAMLs, or '-' for stdin. Can be repeated.")
+	importCrdCmd.Flags().StringVar(&importCrdArgs.kustomize, "kustomize", "",
+		"Path to a kustomization directory to build and import CRDs from.")
+	importCrdCmd.Flags().StringVar(&importCrdArgs.crdURL, "url", "",
+		"HTTPS URL to a Kubernetes CRD YAML.")
+	importCrdCmd.Flags().StringVar(&importCrdArgs.crdSHA256, "sha256", "",
+		"The SHA-256 checksum of the file downloaded with --url.")
+	importCrdCmd.Flags().BoolVar(&importCrdArgs.fromCluster, "from-cluster", false,
+		"Fetch the CustomResourceDefinitions installed on the target cluster.")
+	importCrdCmd.Flags().StringVar(&importCrdArgs.crdGroup, "group", "",
+		"Limit --from-cluster to CRDs belonging to the given API group.")
+	importCrdCmd.Flags().StringVar(&importCrdArgs.kubeconfig, "kubeconfig", "",
+		"Absolute path to the kubeconfig file.")
+	importCrdCmd.Flags().StringVar(&importCrdArgs.kubeContext, "context", "",
+		"The name of the kubeconfig context to use.")
+	importCrdCmd.Flags().StringSliceVar(&importCrdArgs.versions, "versions", nil,
+		"Limit the generated CUE definitions to the given comma-separated list of CRD versions.")
+	importCrdCmd.Flags().BoolVar(&importCrdArgs.storageOnly, "storage-only", false,
+		"Only generate CUE definitions for each CRD's storage version.")
+	importCrdCmd.Flags().BoolVar(&importCrdArgs.watch, "watch", false,
+		"Watch the --file/--kustomize inputs and regenerate the CUE definitions on change.")
 
 	modImportCmd.AddCommand(importCrdCmd)
 }
@@ -66,7 +126,6 @@ func runImportCrdCmd(cmd *cobra.Command, args []string) error {
 	}
 
 	log := LoggerFrom(cmd.Context())
-	cuectx := cuecontext.New()
 
 	// Make sure we're importing into a CUE module.
 	cueModDir := path.Join(importCrdArgs.modRoot, "cue.mod")
@@ -74,66 +133,702 @@ func runImportCrdCmd(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("cue.mod not found in the module path %s", importCrdArgs.modRoot)
 	}
 
-	// Load the YAML file into memory.
-	var crdData []byte
-	if fs, err := os.Stat(importCrdArgs.crdFile); err != nil || !fs.Mode().IsRegular() {
-		return fmt.Errorf("path not found: %s", importCrdArgs.crdFile)
-	}
-
-	f, err := os.Open(importCrdArgs.crdFile)
+	prev, err := generateCRDModule(cmd, cueModDir)
 	if err != nil {
 		return err
 	}
-
-	crdData, err = io.ReadAll(f)
-	if err != nil {
+	if err := writeGenerated(log, cueModDir, prev); err != nil {
 		return err
 	}
 
-	// Extract the Kubernetes CRDs from the multi-doc YAML.
-	var builder strings.Builder
-	objects, err := ssa.ReadObjects(bytes.NewReader(crdData))
-	if err != nil {
-		return fmt.Errorf("parsing CRDs failed: %w", err)
+	if !importCrdArgs.watch {
+		return nil
+	}
+
+	if importCrdArgs.fromCluster || importCrdArgs.crdURL != "" {
+		return fmt.Errorf("--watch only supports --file and --kustomize inputs")
 	}
-	for _, object := range objects {
-		if object.GetKind() == "CustomResourceDefinition" {
-			builder.WriteString("---\n")
-			data, err := yaml.Marshal(object)
+
+	return watchCRDSources(cmd, log, cueModDir, prev)
+}
+
+// generateCRDModule loads the configured CRD sources, applies the
+// --versions/--storage-only filters and runs the CUE generator, returning
+// the full set of files that belong under cueModDir/gen keyed by their
+// path relative to it.
+func generateCRDModule(cmd *cobra.Command, cueModDir string) (map[string][]byte, error) {
+	cuectx := cuecontext.New()
+
+	// Load the CRD YAML into memory from one of the supported sources.
+	var sources []crdSource
+	switch {
+	case importCrdArgs.fromCluster:
+		data, err := fetchCRDsFromCluster(cmd.Context(), importCrdArgs.kubeconfig, importCrdArgs.kubeContext, importCrdArgs.crdGroup)
+		if err != nil {
+			return nil, fmt.Errorf("listing CRDs from cluster failed: %w", err)
+		}
+		sources = []crdSource{{origin: "--from-cluster", data: data}}
+	case importCrdArgs.crdURL != "":
+		data, err := fetchCRDFromURL(cmd.Context(), importCrdArgs.crdURL, importCrdArgs.crdSHA256)
+		if err != nil {
+			return nil, fmt.Errorf("fetching CRD from URL failed: %w", err)
+		}
+		sources = []crdSource{{origin: importCrdArgs.crdURL, data: data}}
+	default:
+		fileSources, err := loadCRDFileSources(importCrdArgs.crdFiles)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, fileSources...)
+
+		if importCrdArgs.kustomize != "" {
+			data, err := buildKustomization(importCrdArgs.kustomize)
 			if err != nil {
-				return fmt.Errorf("marshaling CRD failed: %w", err)
+				return nil, fmt.Errorf("building kustomization %s failed: %w", importCrdArgs.kustomize, err)
 			}
-			builder.Write(data)
+			sources = append(sources, crdSource{origin: importCrdArgs.kustomize, data: data})
+		}
+
+		if len(sources) == 0 {
+			return nil, fmt.Errorf("at least one of --file or --kustomize is required")
 		}
 	}
 
-	// Generate the CUE definitions from the given CRD YAML.
-	imp := engine.NewImporter(cuectx, fmt.Sprintf("%s%s", header, importCrdArgs.crdFile))
-	crds, err := imp.Generate([]byte(builder.String()))
+	// Extract the Kubernetes CRDs from the multi-doc YAML, de-duplicating by
+	// name across sources and rejecting sources that disagree.
+	crds, err := mergeCRDSources(sources)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Sort the resulting definitions based on file names.
-	keys := make([]string, 0, len(crds))
-	for k := range crds {
+	// Render one CUE file per surviving version for each CRD, plus a
+	// top-level #Versions alias describing its served/storage versions and
+	// conversion strategy.
+	files := make(map[string][]byte)
+	for _, crd := range crds {
+		versions, err := filterCRDVersions(crd, importCrdArgs.versions, importCrdArgs.storageOnly)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", crd.GetName(), err)
+		}
+
+		// #Versions must reflect the CRD's full served/storage truth, not the
+		// --versions/--storage-only-filtered subset that's actually rendered
+		// to CUE below.
+		allVersions, err := filterCRDVersions(crd, nil, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", crd.GetName(), err)
+		}
+
+		strategy := conversionStrategy(crd)
+
+		for _, v := range versions {
+			data, err := yaml.Marshal(singleVersionCRD(crd, v.Name))
+			if err != nil {
+				return nil, fmt.Errorf("marshaling CRD failed: %w", err)
+			}
+
+			imp := engine.NewImporter(cuectx, fmt.Sprintf("%s%s", header, importCrdSource()))
+			gen, err := imp.Generate(data)
+			if err != nil {
+				return nil, err
+			}
+
+			for k, content := range gen {
+				fileName := fmt.Sprintf("%s_types_gen.cue", v.Name)
+				if len(versions) == 1 {
+					fileName = "types_gen.cue"
+				}
+
+				files[path.Join(k, fileName)] = content
+				files[path.Join(k, "versions_gen.cue")] = engine.VersionsAlias(allVersions, strategy)
+			}
+		}
+	}
+
+	return files, nil
+}
+
+// generatedFileSuffixes lists the file names/suffixes writeGenerated is
+// responsible for, so removeStaleGenerated only ever touches files it
+// produced itself (never a hand-written CUE file sitting in the same
+// package directory).
+var generatedFileSuffixes = []string{"types_gen.cue", "versions_gen.cue"}
+
+// writeGenerated writes files (keyed by path relative to cueModDir/gen) to
+// disk, creating parent directories as needed, then removes any
+// previously-generated file under the same package directories that's no
+// longer part of files -- e.g. a stale v1beta1_types_gen.cue left behind
+// after --versions/--storage-only narrows a CRD's version set.
+func writeGenerated(log logr.Logger, cueModDir string, files map[string][]byte) error {
+	keys := make([]string, 0, len(files))
+	for k := range files {
 		keys = append(keys, k)
 	}
 	sort.Strings(keys)
 
-	// Write the definitions to the module's 'cue.mod/gen' dir.
 	for _, k := range keys {
 		log.Info(fmt.Sprintf("generating: %s", colorizeSubject(k)))
 
-		dstDir := path.Join(cueModDir, "gen", k)
-		if err := os.MkdirAll(dstDir, os.ModePerm); err != nil {
+		dst := path.Join(cueModDir, "gen", k)
+		if err := os.MkdirAll(path.Dir(dst), os.ModePerm); err != nil {
 			return err
 		}
+		if err := os.WriteFile(dst, files[k], 0644); err != nil {
+			return err
+		}
+	}
 
-		if err := os.WriteFile(path.Join(dstDir, "types_gen.cue"), crds[k], 0644); err != nil {
+	return removeStaleGenerated(log, cueModDir, files)
+}
+
+// removeStaleGenerated deletes previously-generated files left behind under
+// every package directory files writes into, when they're no longer part of
+// the current file set. Without this, a CRD package directory can end up
+// with two files defining the same #Kind with incompatible schemas (e.g. an
+// old v1beta1_types_gen.cue next to a new types_gen.cue), which fails to
+// unify when the module is loaded.
+func removeStaleGenerated(log logr.Logger, cueModDir string, files map[string][]byte) error {
+	dirs := make(map[string]bool)
+	for k := range files {
+		dirs[path.Dir(k)] = true
+	}
+
+	for dir := range dirs {
+		abs := path.Join(cueModDir, "gen", dir)
+		entries, err := os.ReadDir(abs)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
 			return err
 		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !isGeneratedFileName(entry.Name()) {
+				continue
+			}
+
+			rel := path.Join(dir, entry.Name())
+			if _, ok := files[rel]; ok {
+				continue
+			}
+
+			log.Info(fmt.Sprintf("removing: %s", colorizeSubject(rel)))
+			if err := os.Remove(path.Join(abs, entry.Name())); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
 }
+
+// isGeneratedFileName reports whether name matches one of the file names
+// writeGenerated produces.
+func isGeneratedFileName(name string) bool {
+	for _, suffix := range generatedFileSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchCRDSources monitors the --file/--kustomize inputs with fsnotify and
+// regenerates the CUE definitions on every change, printing a unified diff
+// of the affected files (additions, changes and removals) and rewriting the
+// generated files whenever any of them changed. writeGenerated deletes any stale per-version file left
+// behind by a version disappearing mid-session, so an edit that renames or
+// drops a CRD version doesn't leave an incompatible file sitting in
+// cue.mod/gen for a later `timoni mod vendor`/build to trip over.
+func watchCRDSources(cmd *cobra.Command, log logr.Logger, cueModDir string, prev map[string][]byte) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	watchPaths := append([]string{}, importCrdArgs.crdFiles...)
+	if importCrdArgs.kustomize != "" {
+		watchPaths = append(watchPaths, importCrdArgs.kustomize)
+	}
+	if err := addWatchPaths(watcher, watchPaths); err != nil {
+		return err
+	}
+
+	log.Info(fmt.Sprintf("watching for changes in %s", strings.Join(watchPaths, ", ")))
+
+	for {
+		select {
+		case <-cmd.Context().Done():
+			return cmd.Context().Err()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					// A new subdirectory needs its own watch, and
+					// loadCRDFileSources walks subdirectories recursively,
+					// so the watcher must match or files created inside it
+					// would never trigger a regeneration.
+					if err := addWatchPaths(watcher, []string{event.Name}); err != nil {
+						log.Error(err, "failed to watch new directory")
+					}
+				}
+			}
+
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Remove|fsnotify.Rename) == 0 {
+				continue
+			}
+
+			next, err := generateCRDModule(cmd, cueModDir)
+			if err != nil {
+				log.Error(err, "regeneration failed")
+				continue
+			}
+
+			changed := diffGenerated(prev, next)
+			if len(changed) == 0 {
+				continue
+			}
+			for _, d := range changed {
+				fmt.Fprintln(cmd.OutOrStdout(), d)
+			}
+			if err := writeGenerated(log, cueModDir, next); err != nil {
+				return err
+			}
+
+			prev = next
+		}
+	}
+}
+
+// addWatchPaths adds each of paths to watcher. A directory is walked
+// recursively and every subdirectory is added individually, since fsnotify
+// only watches the immediate contents of a directory, not its subtree --
+// matching loadCRDFileSources, which walks directories recursively for
+// *.yaml/*.yml files.
+func addWatchPaths(watcher *fsnotify.Watcher, paths []string) error {
+	for _, p := range paths {
+		if p == "-" {
+			continue
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("watching %s failed: %w", p, err)
+		}
+
+		if !info.IsDir() {
+			if err := watcher.Add(p); err != nil {
+				return fmt.Errorf("watching %s failed: %w", p, err)
+			}
+			continue
+		}
+
+		err = filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			return watcher.Add(fp)
+		})
+		if err != nil {
+			return fmt.Errorf("watching %s failed: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// diffGenerated returns a unified diff for every file that's new, changed or
+// removed between prev and next: entries only in prev diff against an empty
+// "next" side, reporting the removal.
+func diffGenerated(prev, next map[string][]byte) []string {
+	var diffs []string
+
+	keys := make([]string, 0, len(next)+len(prev))
+	seen := make(map[string]bool, len(next)+len(prev))
+	for k := range next {
+		keys = append(keys, k)
+		seen[k] = true
+	}
+	for k := range prev {
+		if !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		old, existedBefore := prev[k]
+		updated, existsNow := next[k]
+		if existedBefore && existsNow && bytes.Equal(old, updated) {
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(old)),
+			B:        difflib.SplitLines(string(updated)),
+			FromFile: k,
+			ToFile:   k,
+			Context:  3,
+		})
+		if err != nil {
+			continue
+		}
+		diffs = append(diffs, diff)
+	}
+
+	return diffs
+}
+
+// importCrdSource returns a human-readable description of where the CRD
+// data was pulled from, used to annotate the generated CUE header.
+func importCrdSource() string {
+	switch {
+	case importCrdArgs.fromCluster:
+		return "--from-cluster"
+	case importCrdArgs.crdURL != "":
+		return importCrdArgs.crdURL
+	case importCrdArgs.kustomize != "":
+		return fmt.Sprintf("--kustomize %s", importCrdArgs.kustomize)
+	default:
+		return strings.Join(importCrdArgs.crdFiles, " ")
+	}
+}
+
+// crdSource pairs raw CRD YAML with a human-readable origin, used to
+// produce clear conflict errors when two sources disagree.
+type crdSource struct {
+	origin string
+	data   []byte
+}
+
+// loadCRDFileSources reads the given -f paths into crdSources. A path of
+// "-" reads from stdin, a directory is walked recursively for *.yaml/*.yml
+// files (mirroring `kubectl apply -f`), and anything else is read as a
+// single file.
+func loadCRDFileSources(paths []string) ([]crdSource, error) {
+	var sources []crdSource
+
+	for _, p := range paths {
+		if p == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("reading stdin failed: %w", err)
+			}
+			sources = append(sources, crdSource{origin: "stdin", data: data})
+			continue
+		}
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("path not found: %s", p)
+		}
+
+		if !info.IsDir() {
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			sources = append(sources, crdSource{origin: p, data: data})
+			continue
+		}
+
+		err = filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			ext := filepath.Ext(fp)
+			if ext != ".yaml" && ext != ".yml" {
+				return nil
+			}
+
+			data, err := os.ReadFile(fp)
+			if err != nil {
+				return err
+			}
+			sources = append(sources, crdSource{origin: fp, data: data})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking directory %s failed: %w", p, err)
+		}
+	}
+
+	return sources, nil
+}
+
+// buildKustomization runs `kustomize build` against dir and returns the
+// rendered multi-doc YAML.
+func buildKustomization(dir string) ([]byte, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, err
+	}
+	return resMap.AsYaml()
+}
+
+// mergeCRDSources extracts the CustomResourceDefinition objects out of each
+// source's YAML, de-duplicating by name across sources. Two sources that
+// define the same CRD name with a different spec.versions[].schema produce a
+// conflict error naming both origins, rather than silently picking one;
+// incidental metadata differences (e.g. annotations or labels added by a
+// kustomize overlay) are not compared and so don't trigger a conflict. The
+// returned objects are ordered by name.
+func mergeCRDSources(sources []crdSource) ([]*unstructured.Unstructured, error) {
+	type seenCRD struct {
+		origin      string
+		fingerprint []byte
+	}
+
+	seen := make(map[string]seenCRD)
+	order := make([]string, 0)
+	byName := make(map[string]*unstructured.Unstructured)
+
+	for _, src := range sources {
+		objects, err := ssa.ReadObjects(bytes.NewReader(src.data))
+		if err != nil {
+			return nil, fmt.Errorf("parsing CRDs from %s failed: %w", src.origin, err)
+		}
+
+		for _, object := range objects {
+			if object.GetKind() != "CustomResourceDefinition" {
+				continue
+			}
+
+			fingerprint, err := crdSchemaFingerprint(object)
+			if err != nil {
+				return nil, fmt.Errorf("reading spec.versions from %s failed: %w", src.origin, err)
+			}
+
+			name := object.GetName()
+			if prev, ok := seen[name]; ok {
+				if !bytes.Equal(prev.fingerprint, fingerprint) {
+					return nil, fmt.Errorf("conflicting definitions for %s in %s and %s", name, prev.origin, src.origin)
+				}
+				continue
+			}
+			seen[name] = seenCRD{origin: src.origin, fingerprint: fingerprint}
+			byName[name] = object
+			order = append(order, name)
+		}
+	}
+
+	sort.Strings(order)
+	result := make([]*unstructured.Unstructured, 0, len(order))
+	for _, name := range order {
+		result = append(result, byName[name])
+	}
+	return result, nil
+}
+
+// crdSchemaFingerprint returns a byte-comparable encoding of crd's
+// spec.versions[].{name,schema}, used by mergeCRDSources to detect conflicts
+// scoped to the versions' schemas rather than the whole object.
+func crdSchemaFingerprint(crd *unstructured.Unstructured) ([]byte, error) {
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return nil, err
+	}
+
+	type versionSchema struct {
+		Name   string      `json:"name"`
+		Schema interface{} `json:"schema,omitempty"`
+	}
+
+	schemas := make([]versionSchema, 0, len(versions))
+	for _, v := range versions {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["name"].(string)
+		schemas = append(schemas, versionSchema{Name: name, Schema: m["schema"]})
+	}
+
+	// encoding/json sorts map keys, so two schemas that are structurally
+	// equal marshal to identical bytes regardless of field order.
+	return json.Marshal(schemas)
+}
+
+// filterCRDVersions returns the spec.versions of crd that survive the
+// --versions allow-list and --storage-only filters, in their declared
+// order. It errors if the filters would leave no version behind.
+func filterCRDVersions(crd *unstructured.Unstructured, allow []string, storageOnly bool) ([]engine.CRDVersionInfo, error) {
+	raw, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return nil, fmt.Errorf("reading spec.versions failed: %w", err)
+	}
+
+	var kept []engine.CRDVersionInfo
+	for _, item := range raw {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name, _ := m["name"].(string)
+		served, _ := m["served"].(bool)
+		storage, _ := m["storage"].(bool)
+
+		if storageOnly && !storage {
+			continue
+		}
+		if len(allow) > 0 && !containsString(allow, name) {
+			continue
+		}
+
+		kept = append(kept, engine.CRDVersionInfo{Name: name, Served: served, Storage: storage})
+	}
+
+	if len(kept) == 0 {
+		return nil, fmt.Errorf("no version left after applying --versions/--storage-only filters")
+	}
+
+	return kept, nil
+}
+
+// singleVersionCRD returns a deep copy of crd with spec.versions reduced to
+// the single named version, so it can be handed to the importer on its own.
+func singleVersionCRD(crd *unstructured.Unstructured, version string) *unstructured.Unstructured {
+	out := crd.DeepCopy()
+
+	raw, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, item := range raw {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := m["name"].(string); name == version {
+			_ = unstructured.SetNestedSlice(out.Object, []interface{}{item}, "spec", "versions")
+			break
+		}
+	}
+
+	return out
+}
+
+// conversionStrategy returns the CRD's spec.conversion.strategy, defaulting
+// to "None" when unset as the Kubernetes API does.
+func conversionStrategy(crd *unstructured.Unstructured) string {
+	strategy, found, _ := unstructured.NestedString(crd.Object, "spec", "conversion", "strategy")
+	if !found || strategy == "" {
+		return "None"
+	}
+	return strategy
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchCRDFromURL downloads a CRD YAML document over HTTPS and, when sha256
+// is non-empty, verifies the payload against it before returning the bytes.
+func fetchCRDFromURL(ctx context.Context, url, sha256sum string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if sha256sum != "" {
+		sum := sha256.Sum256(data)
+		got := hex.EncodeToString(sum[:])
+		if got != sha256sum {
+			return nil, fmt.Errorf("checksum mismatch for %s: got %s, expected %s", url, got, sha256sum)
+		}
+	}
+
+	return data, nil
+}
+
+// fetchCRDsFromCluster connects to a Kubernetes cluster and returns the
+// CustomResourceDefinitions installed on it, optionally filtered by API
+// group, marshaled as a single multi-doc YAML stream.
+func fetchCRDsFromCluster(ctx context.Context, kubeconfig, kubeContext, group string) ([]byte, error) {
+	configFlags := genericclioptions.NewConfigFlags(false)
+	if kubeconfig != "" {
+		configFlags.KubeConfig = &kubeconfig
+	}
+	if kubeContext != "" {
+		configFlags.Context = &kubeContext
+	}
+
+	restConfig, err := configFlags.ToRESTConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig failed: %w", err)
+	}
+
+	clientset, err := apiextensionsclientset.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := clientset.ApiextensionsV1().CustomResourceDefinitions().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var builder strings.Builder
+	for _, crd := range list.Items {
+		crd := crd
+		if group != "" && crd.Spec.Group != group {
+			continue
+		}
+
+		crd.TypeMeta = metav1.TypeMeta{
+			Kind:       "CustomResourceDefinition",
+			APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+		}
+		crd.ManagedFields = nil
+		crd.ResourceVersion = ""
+		crd.UID = ""
+
+		data, err := yaml.Marshal(crd)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling CRD %s failed: %w", crd.Name, err)
+		}
+
+		builder.WriteString("---\n")
+		builder.Write(data)
+	}
+
+	return []byte(builder.String()), nil
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/spf13/cobra"
+
+	"github.com/stefanprodan/timoni/internal/engine"
+)
+
+var importCrdGoCmd = &cobra.Command{
+	Use:   "crd-go [MODULE PATH]",
+	Short: "Generate CUE definitions from Go CRD types",
+	Example: `  # generate CUE definitions from the CRD types of a Go package
+  timoni mod import crd-go --package ./api/v1
+`,
+	RunE: runImportCrdGoCmd,
+}
+
+type importCrdGoFlags struct {
+	modRoot string
+	pkg     string
+}
+
+var importCrdGoArgs importCrdGoFlags
+
+func init() {
+	importCrdGoCmd.Flags().StringVar(&importCrdGoArgs.pkg, "package", "",
+		"The Go module path or package pattern containing the CRD types.")
+
+	modImportCmd.AddCommand(importCrdGoCmd)
+}
+
+const headerGo = `// Code generated by timoni. DO NOT EDIT.
+
+//timoni:generate timoni import crd-go --package `
+
+func runImportCrdGoCmd(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		importCrdGoArgs.modRoot = args[0]
+	}
+
+	if importCrdGoArgs.pkg == "" {
+		return fmt.Errorf("--package is required")
+	}
+
+	log := LoggerFrom(cmd.Context())
+	cuectx := cuecontext.New()
+
+	// Make sure we're importing into a CUE module.
+	cueModDir := path.Join(importCrdGoArgs.modRoot, "cue.mod")
+	if fs, err := os.Stat(cueModDir); err != nil || !fs.IsDir() {
+		return fmt.Errorf("cue.mod not found in the module path %s", importCrdGoArgs.modRoot)
+	}
+
+	// Load the Go package(s) and synthesize CustomResourceDefinition objects
+	// from the types annotated with +kubebuilder: markers.
+	crdData, err := engine.GenerateCRDsFromGo(importCrdGoArgs.pkg)
+	if err != nil {
+		return fmt.Errorf("loading Go CRD types failed: %w", err)
+	}
+
+	// Generate the CUE definitions from the synthesized CRD YAML.
+	imp := engine.NewImporter(cuectx, fmt.Sprintf("%s%s", headerGo, importCrdGoArgs.pkg))
+	crds, err := imp.Generate(crdData)
+	if err != nil {
+		return err
+	}
+
+	// Sort the resulting definitions based on file names.
+	keys := make([]string, 0, len(crds))
+	for k := range crds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Write the definitions to the module's 'cue.mod/gen' dir.
+	for _, k := range keys {
+		log.Info(fmt.Sprintf("generating: %s", colorizeSubject(k)))
+
+		dstDir := path.Join(cueModDir, "gen", k)
+		if err := os.MkdirAll(dstDir, os.ModePerm); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(path.Join(dstDir, "types_gen.cue"), crds[k], 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
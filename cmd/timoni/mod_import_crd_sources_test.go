@@ -0,0 +1,171 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testCRDBase = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            properties:
+              size:
+                type: string
+`
+
+func TestMergeCRDSources(t *testing.T) {
+	t.Run("dedups identical CRD across sources", func(t *testing.T) {
+		sources := []crdSource{
+			{origin: "a.yaml", data: []byte(testCRDBase)},
+			{origin: "b.yaml", data: []byte(testCRDBase)},
+		}
+		crds, err := mergeCRDSources(sources)
+		if err != nil {
+			t.Fatalf("mergeCRDSources() failed: %v", err)
+		}
+		if len(crds) != 1 {
+			t.Fatalf("mergeCRDSources() returned %d CRDs, want 1", len(crds))
+		}
+	})
+
+	t.Run("merges when only incidental metadata differs", func(t *testing.T) {
+		withLabel := strings.Replace(testCRDBase, "metadata:\n  name: widgets.example.com",
+			"metadata:\n  name: widgets.example.com\n  labels:\n    app: example", 1)
+
+		sources := []crdSource{
+			{origin: "a.yaml", data: []byte(testCRDBase)},
+			{origin: "b.yaml", data: []byte(withLabel)},
+		}
+		crds, err := mergeCRDSources(sources)
+		if err != nil {
+			t.Fatalf("mergeCRDSources() failed for sources differing only in metadata: %v", err)
+		}
+		if len(crds) != 1 {
+			t.Fatalf("mergeCRDSources() returned %d CRDs, want 1", len(crds))
+		}
+	})
+
+	t.Run("conflicts when the schema differs", func(t *testing.T) {
+		conflicting := strings.Replace(testCRDBase, "size:\n                type: string",
+			"size:\n                type: integer", 1)
+
+		sources := []crdSource{
+			{origin: "a.yaml", data: []byte(testCRDBase)},
+			{origin: "b.yaml", data: []byte(conflicting)},
+		}
+		_, err := mergeCRDSources(sources)
+		if err == nil {
+			t.Fatal("mergeCRDSources() succeeded, want conflict error for differing schemas")
+		}
+		if !strings.Contains(err.Error(), "a.yaml") || !strings.Contains(err.Error(), "b.yaml") {
+			t.Errorf("mergeCRDSources() error %q doesn't name both origins", err)
+		}
+	})
+}
+
+func TestLoadCRDFileSources(t *testing.T) {
+	t.Run("single file", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "widget.yaml")
+		if err := os.WriteFile(file, []byte(testCRDBase), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		sources, err := loadCRDFileSources([]string{file})
+		if err != nil {
+			t.Fatalf("loadCRDFileSources() failed: %v", err)
+		}
+		if len(sources) != 1 || sources[0].origin != file {
+			t.Fatalf("loadCRDFileSources() = %+v, want one source from %s", sources, file)
+		}
+	})
+
+	t.Run("directory is walked recursively", func(t *testing.T) {
+		dir := t.TempDir()
+		nested := filepath.Join(dir, "nested")
+		if err := os.MkdirAll(nested, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "top.yaml"), []byte(testCRDBase), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(nested, "child.yml"), []byte(testCRDBase), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignore me"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		sources, err := loadCRDFileSources([]string{dir})
+		if err != nil {
+			t.Fatalf("loadCRDFileSources() failed: %v", err)
+		}
+		if len(sources) != 2 {
+			t.Fatalf("loadCRDFileSources() returned %d sources, want 2 (non-YAML files skipped): %+v", len(sources), sources)
+		}
+	})
+
+	t.Run("dash reads from stdin", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		origStdin := os.Stdin
+		os.Stdin = r
+		defer func() { os.Stdin = origStdin }()
+
+		go func() {
+			_, _ = w.WriteString(testCRDBase)
+			w.Close()
+		}()
+
+		sources, err := loadCRDFileSources([]string{"-"})
+		if err != nil {
+			t.Fatalf("loadCRDFileSources() failed: %v", err)
+		}
+		if len(sources) != 1 || sources[0].origin != "stdin" {
+			t.Fatalf("loadCRDFileSources() = %+v, want one source from stdin", sources)
+		}
+	})
+
+	t.Run("missing path errors", func(t *testing.T) {
+		_, err := loadCRDFileSources([]string{filepath.Join(t.TempDir(), "missing.yaml")})
+		if err == nil {
+			t.Fatal("loadCRDFileSources() succeeded, want error for missing path")
+		}
+	})
+}
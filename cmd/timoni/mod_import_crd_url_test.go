@@ -0,0 +1,76 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchCRDFromURL(t *testing.T) {
+	body := []byte("apiVersion: apiextensions.k8s.io/v1\nkind: CustomResourceDefinition\n")
+	sum := sha256.Sum256(body)
+	checksum := hex.EncodeToString(sum[:])
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	t.Run("no checksum", func(t *testing.T) {
+		data, err := fetchCRDFromURL(context.Background(), srv.URL, "")
+		if err != nil {
+			t.Fatalf("fetchCRDFromURL() failed: %v", err)
+		}
+		if string(data) != string(body) {
+			t.Errorf("fetchCRDFromURL() = %q, want %q", data, body)
+		}
+	})
+
+	t.Run("matching checksum", func(t *testing.T) {
+		data, err := fetchCRDFromURL(context.Background(), srv.URL, checksum)
+		if err != nil {
+			t.Fatalf("fetchCRDFromURL() failed: %v", err)
+		}
+		if string(data) != string(body) {
+			t.Errorf("fetchCRDFromURL() = %q, want %q", data, body)
+		}
+	})
+
+	t.Run("mismatched checksum", func(t *testing.T) {
+		_, err := fetchCRDFromURL(context.Background(), srv.URL, "0000000000000000000000000000000000000000000000000000000000000000")
+		if err == nil {
+			t.Fatal("fetchCRDFromURL() succeeded, want checksum mismatch error")
+		}
+	})
+
+	t.Run("non-200 status", func(t *testing.T) {
+		errSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer errSrv.Close()
+
+		_, err := fetchCRDFromURL(context.Background(), errSrv.URL, "")
+		if err == nil {
+			t.Fatal("fetchCRDFromURL() succeeded, want error for 404 response")
+		}
+	})
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testMultiVersionCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+  versions:
+  - name: v1beta1
+    served: true
+    storage: false
+    schema:
+      openAPIV3Schema:
+        type: object
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+`
+
+func parseTestCRD(t *testing.T) *unstructured.Unstructured {
+	t.Helper()
+	objects, err := ssa.ReadObjects(bytes.NewReader([]byte(testMultiVersionCRD)))
+	if err != nil {
+		t.Fatalf("parsing test CRD failed: %v", err)
+	}
+	if len(objects) != 1 {
+		t.Fatalf("parsed %d objects, want 1", len(objects))
+	}
+	return objects[0]
+}
+
+func TestFilterCRDVersions(t *testing.T) {
+	t.Run("no filters keeps every version", func(t *testing.T) {
+		crd := parseTestCRD(t)
+		versions, err := filterCRDVersions(crd, nil, false)
+		if err != nil {
+			t.Fatalf("filterCRDVersions() failed: %v", err)
+		}
+		if len(versions) != 2 {
+			t.Fatalf("filterCRDVersions() = %+v, want 2 versions", versions)
+		}
+	})
+
+	t.Run("storage-only keeps the storage version", func(t *testing.T) {
+		crd := parseTestCRD(t)
+		versions, err := filterCRDVersions(crd, nil, true)
+		if err != nil {
+			t.Fatalf("filterCRDVersions() failed: %v", err)
+		}
+		if len(versions) != 1 || versions[0].Name != "v1" {
+			t.Fatalf("filterCRDVersions() = %+v, want only v1", versions)
+		}
+	})
+
+	t.Run("versions allow-list narrows the set", func(t *testing.T) {
+		crd := parseTestCRD(t)
+		versions, err := filterCRDVersions(crd, []string{"v1beta1"}, false)
+		if err != nil {
+			t.Fatalf("filterCRDVersions() failed: %v", err)
+		}
+		if len(versions) != 1 || versions[0].Name != "v1beta1" {
+			t.Fatalf("filterCRDVersions() = %+v, want only v1beta1", versions)
+		}
+	})
+
+	t.Run("filtering out every version errors", func(t *testing.T) {
+		crd := parseTestCRD(t)
+		_, err := filterCRDVersions(crd, []string{"does-not-exist"}, false)
+		if err == nil {
+			t.Fatal("filterCRDVersions() succeeded, want error when no version survives")
+		}
+	})
+}
+
+func TestSingleVersionCRD(t *testing.T) {
+	crd := parseTestCRD(t)
+
+	single := singleVersionCRD(crd, "v1")
+	versions, err := filterCRDVersions(single, nil, false)
+	if err != nil {
+		t.Fatalf("filterCRDVersions() on single-version CRD failed: %v", err)
+	}
+	if len(versions) != 1 || versions[0].Name != "v1" {
+		t.Fatalf("singleVersionCRD() left %+v, want only v1", versions)
+	}
+
+	// The original object is untouched.
+	original, err := filterCRDVersions(crd, nil, false)
+	if err != nil {
+		t.Fatalf("filterCRDVersions() on original CRD failed: %v", err)
+	}
+	if len(original) != 2 {
+		t.Fatalf("singleVersionCRD() mutated its input: %+v", original)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestDiffGenerated(t *testing.T) {
+	t.Run("no changes", func(t *testing.T) {
+		prev := map[string][]byte{"a/types_gen.cue": []byte("#A: {}\n")}
+		next := map[string][]byte{"a/types_gen.cue": []byte("#A: {}\n")}
+		if diffs := diffGenerated(prev, next); len(diffs) != 0 {
+			t.Errorf("diffGenerated() = %d diffs, want 0 for identical input", len(diffs))
+		}
+	})
+
+	t.Run("new file", func(t *testing.T) {
+		prev := map[string][]byte{}
+		next := map[string][]byte{"a/types_gen.cue": []byte("#A: {}\n")}
+		diffs := diffGenerated(prev, next)
+		if len(diffs) != 1 {
+			t.Fatalf("diffGenerated() = %d diffs, want 1 for a new file", len(diffs))
+		}
+		if !strings.Contains(diffs[0], "+#A") {
+			t.Errorf("diffGenerated() diff %q doesn't show the addition", diffs[0])
+		}
+	})
+
+	t.Run("changed file", func(t *testing.T) {
+		prev := map[string][]byte{"a/types_gen.cue": []byte("#A: {x: int}\n")}
+		next := map[string][]byte{"a/types_gen.cue": []byte("#A: {x: string}\n")}
+		diffs := diffGenerated(prev, next)
+		if len(diffs) != 1 {
+			t.Fatalf("diffGenerated() = %d diffs, want 1 for a changed file", len(diffs))
+		}
+	})
+
+	t.Run("removed file", func(t *testing.T) {
+		prev := map[string][]byte{
+			"a/types_gen.cue":         []byte("#A: {}\n"),
+			"a/v1beta1_types_gen.cue": []byte("#A: {}\n"),
+		}
+		next := map[string][]byte{
+			"a/types_gen.cue": []byte("#A: {}\n"),
+		}
+		diffs := diffGenerated(prev, next)
+		if len(diffs) != 1 {
+			t.Fatalf("diffGenerated() = %d diffs, want 1 for a removed file", len(diffs))
+		}
+		if !strings.Contains(diffs[0], "v1beta1_types_gen.cue") {
+			t.Errorf("diffGenerated() diff %q doesn't name the removed file", diffs[0])
+		}
+		if !strings.Contains(diffs[0], "-#A") {
+			t.Errorf("diffGenerated() diff %q doesn't show the removal", diffs[0])
+		}
+	})
+}
+
+func TestAddWatchPaths(t *testing.T) {
+	dir := t.TempDir()
+	nested := filepath.Join(dir, "nested")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "widget.yaml")
+	if err := os.WriteFile(file, []byte(testCRDBase), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	if err := addWatchPaths(watcher, []string{dir, file, "-"}); err != nil {
+		t.Fatalf("addWatchPaths() failed: %v", err)
+	}
+
+	watched := watcher.WatchList()
+	want := map[string]bool{dir: false, nested: false, file: false}
+	for _, w := range watched {
+		if _, ok := want[w]; ok {
+			want[w] = true
+		}
+	}
+	for p, ok := range want {
+		if !ok {
+			t.Errorf("addWatchPaths() didn't watch %s; watched: %v", p, watched)
+		}
+	}
+}
+
+func TestAddWatchPathsMissingPath(t *testing.T) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	if err := addWatchPaths(watcher, []string{filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Fatal("addWatchPaths() succeeded, want error for a missing path")
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// cueConstraint lowers the OpenAPI v3 validation keywords set on schema into
+// a CUE constraint expression that narrows the given base type, e.g. an
+// "int" field with `minimum: 1, maximum: 10` becomes "int & >=1 & <=10", and
+// a "string" field with `pattern: "^a.*"` becomes `string & =~"^a.*"`.
+//
+// renderCUEField (see importer.go) calls this for every leaf field of a
+// structural schema and conjoins the result with the field's base CUE type,
+// instead of emitting the unconstrained base type on its own.
+func cueConstraint(base string, schema *apiextensionsv1.JSONSchemaProps) string {
+	if schema == nil {
+		return base
+	}
+
+	var terms []string
+
+	if len(schema.Enum) > 0 {
+		// An enum fully determines the field's value set, so it replaces
+		// rather than narrows the base type.
+		disjuncts := make([]string, 0, len(schema.Enum))
+		for _, v := range schema.Enum {
+			disjuncts = append(disjuncts, string(v.Raw))
+		}
+		return strings.Join(disjuncts, " | ")
+	}
+
+	if schema.XIntOrString {
+		return "int | string"
+	}
+
+	if schema.Minimum != nil {
+		op := ">="
+		if schema.ExclusiveMinimum {
+			op = ">"
+		}
+		terms = append(terms, fmt.Sprintf("%s%s", op, formatNumber(*schema.Minimum)))
+	}
+	if schema.Maximum != nil {
+		op := "<="
+		if schema.ExclusiveMaximum {
+			op = "<"
+		}
+		terms = append(terms, fmt.Sprintf("%s%s", op, formatNumber(*schema.Maximum)))
+	}
+	if schema.MinLength != nil {
+		terms = append(terms, fmt.Sprintf("strings.MinRunes(%d)", *schema.MinLength))
+	}
+	if schema.MaxLength != nil {
+		terms = append(terms, fmt.Sprintf("strings.MaxRunes(%d)", *schema.MaxLength))
+	}
+	if schema.Pattern != "" {
+		terms = append(terms, fmt.Sprintf("=~%q", schema.Pattern))
+	}
+	if schema.MinItems != nil {
+		terms = append(terms, fmt.Sprintf("list.MinItems(%d)", *schema.MinItems))
+	}
+	if schema.MaxItems != nil {
+		terms = append(terms, fmt.Sprintf("list.MaxItems(%d)", *schema.MaxItems))
+	}
+	if schema.UniqueItems {
+		terms = append(terms, "list.UniqueItems()")
+	}
+	if format, ok := formatConstraint(schema.Format); ok {
+		terms = append(terms, format)
+	}
+
+	if len(terms) == 0 {
+		return base
+	}
+
+	all := append([]string{base}, terms...)
+	return strings.Join(all, " & ")
+}
+
+// formatConstraint maps an OpenAPI "format" keyword to a CUE constraint:
+// "date-time" uses the standard library's time.Format validator, "uri",
+// "uuid" and "email" use regular expressions, and "duration" only requires
+// a non-empty string (the duration syntax itself is not checked). Only
+// the formats timoni's importer is documented to recognise are lowered;
+// anything else is left unconstrained since the JSON Schema `format`
+// keyword is advisory, not validating, per the spec.
+func formatConstraint(format string) (string, bool) {
+	switch format {
+	case "date-time":
+		return `time.Format(time.RFC3339)`, true
+	case "duration":
+		return `strings.MinRunes(1)`, true
+	case "uri":
+		return `=~"^[a-zA-Z][a-zA-Z0-9+.-]*://"`, true
+	case "uuid":
+		return `=~"^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$"`, true
+	case "email":
+		return `=~"^[^@\\s]+@[^@\\s]+$"`, true
+	default:
+		return "", false
+	}
+}
+
+// objectConstraint returns the CUE suffix for an object schema's
+// x-kubernetes-preserve-unknown-fields keyword: a struct that allows
+// additional fields of any type stays open (`...`) instead of being closed.
+// renderCUEObject (see importer.go) appends this after a struct's own fields.
+func objectConstraint(schema *apiextensionsv1.JSONSchemaProps) string {
+	if schema != nil && schema.XPreserveUnknownFields != nil && *schema.XPreserveUnknownFields {
+		return "..."
+	}
+	return ""
+}
+
+func formatNumber(f float64) string {
+	if f == float64(int64(f)) {
+		return fmt.Sprintf("%d", int64(f))
+	}
+	return fmt.Sprintf("%g", f)
+}
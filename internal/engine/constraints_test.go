@@ -0,0 +1,157 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// These cases exercise cueConstraint's string builder in isolation, one
+// OpenAPI keyword at a time. TestImporterGenerate (importer_test.go) covers
+// the keywords combined on real CRD-shaped fixtures, compiling the result
+// with the CUE evaluator and asserting that violating instances are
+// rejected.
+func TestCueConstraint(t *testing.T) {
+	ptrF := func(f float64) *float64 { return &f }
+	ptrI := func(i int64) *int64 { return &i }
+
+	tests := []struct {
+		name   string
+		base   string
+		schema *apiextensionsv1.JSONSchemaProps
+		want   string
+	}{
+		{
+			name:   "no constraints",
+			base:   "string",
+			schema: &apiextensionsv1.JSONSchemaProps{},
+			want:   "string",
+		},
+		{
+			name: "minimum and maximum",
+			base: "int",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				Minimum: ptrF(1),
+				Maximum: ptrF(10),
+			},
+			want: "int & >=1 & <=10",
+		},
+		{
+			name: "exclusive bounds",
+			base: "int",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				Minimum:          ptrF(0),
+				ExclusiveMinimum: true,
+			},
+			want: "int & >0",
+		},
+		{
+			name: "string length",
+			base: "string",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				MinLength: ptrI(1),
+				MaxLength: ptrI(63),
+			},
+			want: "string & strings.MinRunes(1) & strings.MaxRunes(63)",
+		},
+		{
+			name: "pattern",
+			base: "string",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				Pattern: "^[a-z0-9]([-a-z0-9]*[a-z0-9])?$",
+			},
+			want: `string & =~"^[a-z0-9]([-a-z0-9]*[a-z0-9])?$"`,
+		},
+		{
+			name: "enum replaces base type",
+			base: "string",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				Enum: []apiextensionsv1.JSON{
+					{Raw: []byte(`"Always"`)},
+					{Raw: []byte(`"IfNotPresent"`)},
+					{Raw: []byte(`"Never"`)},
+				},
+			},
+			want: `"Always" | "IfNotPresent" | "Never"`,
+		},
+		{
+			name: "int or string",
+			base: "_",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				XIntOrString: true,
+			},
+			want: "int | string",
+		},
+		{
+			name: "date-time format",
+			base: "string",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				Format: "date-time",
+			},
+			want: "string & time.Format(time.RFC3339)",
+		},
+		{
+			name: "list constraints",
+			base: "[...string]",
+			schema: &apiextensionsv1.JSONSchemaProps{
+				MinItems:    ptrI(1),
+				UniqueItems: true,
+			},
+			want: "[...string] & list.MinItems(1) & list.UniqueItems()",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cueConstraint(tt.base, tt.schema)
+			if got != tt.want {
+				t.Errorf("cueConstraint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObjectConstraint(t *testing.T) {
+	preserve := true
+
+	tests := []struct {
+		name   string
+		schema *apiextensionsv1.JSONSchemaProps
+		want   string
+	}{
+		{
+			name:   "closed by default",
+			schema: &apiextensionsv1.JSONSchemaProps{},
+			want:   "",
+		},
+		{
+			name:   "preserve unknown fields opens the struct",
+			schema: &apiextensionsv1.JSONSchemaProps{XPreserveUnknownFields: &preserve},
+			want:   "...",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := objectConstraint(tt.schema); got != tt.want {
+				t.Errorf("objectConstraint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// typeMetaID and objectMetaID identify the metav1.TypeMeta and
+// metav1.ObjectMeta types by their package path and name rather than by
+// instance, so that types loaded from different package-load roots (which
+// otherwise produce distinct *types.Named instances for the same type) are
+// still recognised as the same embed.
+const (
+	typeMetaID   = "k8s.io/apimachinery/pkg/apis/meta/v1.TypeMeta"
+	objectMetaID = "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"
+)
+
+// GenerateCRDsFromGo loads the Go package(s) matching pattern and returns a
+// multi-doc YAML stream of CustomResourceDefinition objects synthesized from
+// the exported structs that embed metav1.TypeMeta and metav1.ObjectMeta.
+//
+// Field schemas are derived from the Go struct fields and their
+// +kubebuilder:validation:*, +optional and +listType comment markers. The
+// root type's own doc comment is read for +kubebuilder:resource:scope=.
+func GenerateCRDsFromGo(pattern string) ([]byte, error) {
+	cfg := &packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedTypesInfo |
+			packages.NeedSyntax | packages.NeedDeps | packages.NeedImports,
+	}
+
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("loading package %s failed: %w", pattern, err)
+	}
+	if packages.PrintErrors(pkgs) > 0 {
+		return nil, fmt.Errorf("package %s has errors", pattern)
+	}
+
+	var out strings.Builder
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Syntax {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok.String() != "type" {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					ts, ok := spec.(*ast.TypeSpec)
+					if !ok {
+						continue
+					}
+					st, ok := ts.Type.(*ast.StructType)
+					if !ok || !ts.Name.IsExported() || !isCRDRootType(pkg, st) {
+						continue
+					}
+
+					crd, err := crdFromStruct(pkg, ts.Name.Name, st, gen.Doc)
+					if err != nil {
+						return nil, fmt.Errorf("%s.%s: %w", pkg.PkgPath, ts.Name.Name, err)
+					}
+
+					data, err := yaml.Marshal(crd)
+					if err != nil {
+						return nil, err
+					}
+					out.WriteString("---\n")
+					out.Write(data)
+				}
+			}
+		}
+	}
+
+	return []byte(out.String()), nil
+}
+
+// isCRDRootType reports whether st embeds both metav1.TypeMeta and
+// metav1.ObjectMeta, identifying it as a Kubernetes API root object rather
+// than a nested spec/status struct. Embeds are compared by their qualified
+// type ID instead of the *types.Named pointer, since packages.Load may
+// produce a different instance per load root for the same named type.
+func isCRDRootType(pkg *packages.Package, st *ast.StructType) bool {
+	var hasTypeMeta, hasObjectMeta bool
+	for _, field := range st.Fields.List {
+		if len(field.Names) != 0 {
+			continue // not an embedded field
+		}
+
+		tv, ok := pkg.TypesInfo.Types[field.Type]
+		if !ok {
+			continue
+		}
+
+		named, ok := tv.Type.(*types.Named)
+		if !ok {
+			continue
+		}
+
+		switch qualifiedID(named) {
+		case typeMetaID:
+			hasTypeMeta = true
+		case objectMetaID:
+			hasObjectMeta = true
+		}
+	}
+
+	return hasTypeMeta && hasObjectMeta
+}
+
+// qualifiedID returns the package-path-qualified identifier for a named
+// type, used to compare types across independently loaded packages.
+func qualifiedID(named *types.Named) string {
+	obj := named.Obj()
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+// crdFromStruct synthesizes a CustomResourceDefinition for the given root
+// type, building its OpenAPI v3 schema from the struct's Spec/Status fields
+// and their kubebuilder validation markers, and its scope from the type's
+// own +kubebuilder:resource:scope= doc comment marker.
+func crdFromStruct(pkg *packages.Package, name string, st *ast.StructType, doc *ast.CommentGroup) (*apiextensionsv1.CustomResourceDefinition, error) {
+	group := pkg.PkgPath
+	if idx := strings.LastIndex(group, "/"); idx != -1 {
+		group = group[idx+1:]
+	}
+
+	schema, err := schemaFromStruct(pkg, st)
+	if err != nil {
+		return nil, err
+	}
+
+	plural := strings.ToLower(name) + "s"
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CustomResourceDefinition",
+			APIVersion: apiextensionsv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: fmt.Sprintf("%s.%s", plural, group),
+		},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: group,
+			Names: apiextensionsv1.CustomResourceDefinitionNames{
+				Kind:   name,
+				Plural: plural,
+			},
+			Scope: resourceScope(doc),
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{
+					Name:    "v1",
+					Served:  true,
+					Storage: true,
+					Schema: &apiextensionsv1.CustomResourceValidation{
+						OpenAPIV3Schema: schema,
+					},
+				},
+			},
+		},
+	}
+
+	return crd, nil
+}
+
+// resourceScope reads the +kubebuilder:resource:scope= marker off the root
+// type's doc comment, defaulting to NamespaceScoped as controller-gen does
+// when the marker is absent.
+func resourceScope(doc *ast.CommentGroup) apiextensionsv1.ResourceScope {
+	if doc == nil {
+		return apiextensionsv1.NamespaceScoped
+	}
+
+	for _, c := range doc.List {
+		text := strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), " ")
+		if v := strings.TrimPrefix(text, "+kubebuilder:resource:scope="); v != text {
+			switch v {
+			case "Cluster":
+				return apiextensionsv1.ClusterScoped
+			case "Namespaced":
+				return apiextensionsv1.NamespaceScoped
+			}
+		}
+	}
+
+	return apiextensionsv1.NamespaceScoped
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"go/ast"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// schemaFromStruct builds an OpenAPI v3 schema for a CRD root type from its
+// Spec and Status fields. Only the Spec/Status sub-structs are walked;
+// the embedded TypeMeta/ObjectMeta fields are omitted, matching how
+// controller-gen renders Kubernetes API objects. A field's struct type is
+// expanded only when it is an inline struct literal; a field of a named
+// struct type (e.g. a separate FooSpec type) gets a plain "object" schema
+// with no properties, see openAPIType.
+func schemaFromStruct(pkg *packages.Package, st *ast.StructType) (*apiextensionsv1.JSONSchemaProps, error) {
+	var fields []*ast.Field
+	for _, field := range st.Fields.List {
+		if len(field.Names) == 0 {
+			continue // embedded TypeMeta/ObjectMeta
+		}
+		jsonName, _ := jsonFieldName(field)
+		if jsonName == "spec" || jsonName == "status" {
+			fields = append(fields, field)
+		}
+	}
+
+	return buildObjectSchema(fields)
+}
+
+// buildObjectSchema builds the schema for a struct's fields, expanding
+// inline nested struct types, applying each field's +kubebuilder:validation
+// markers, and marking a field required unless isOptionalField considers it
+// optional.
+func buildObjectSchema(fields []*ast.Field) (*apiextensionsv1.JSONSchemaProps, error) {
+	props := map[string]apiextensionsv1.JSONSchemaProps{}
+	var required []string
+
+	for _, field := range fields {
+		jsonName, inline := jsonFieldName(field)
+		if jsonName == "" || inline {
+			continue
+		}
+
+		fieldSchema, err := schemaFromField(field)
+		if err != nil {
+			return nil, err
+		}
+		props[jsonName] = *fieldSchema
+
+		if !isOptionalField(field) {
+			required = append(required, jsonName)
+		}
+	}
+
+	sort.Strings(required)
+	return &apiextensionsv1.JSONSchemaProps{
+		Type:       "object",
+		Properties: props,
+		Required:   required,
+	}, nil
+}
+
+// schemaFromField builds the schema for a single struct field, expanding
+// inline nested struct types and applying the field's +kubebuilder:validation
+// markers.
+func schemaFromField(field *ast.Field) (*apiextensionsv1.JSONSchemaProps, error) {
+	var schema *apiextensionsv1.JSONSchemaProps
+
+	if st, ok := underlyingStruct(field.Type); ok {
+		nested, err := buildObjectSchema(st.Fields.List)
+		if err != nil {
+			return nil, err
+		}
+		schema = nested
+	} else {
+		schema = &apiextensionsv1.JSONSchemaProps{Type: openAPIType(field.Type)}
+	}
+
+	applyValidationMarkers(schema, field.Doc)
+	return schema, nil
+}
+
+// isOptionalField reports whether field should be left out of its parent
+// object's Required list: because it's a pointer, its json tag carries
+// "omitempty", or its doc comment carries a +optional/
+// +kubebuilder:validation:Optional marker. Everything else is required,
+// matching controller-gen's default.
+func isOptionalField(field *ast.Field) bool {
+	if _, isPtr := field.Type.(*ast.StarExpr); isPtr {
+		return true
+	}
+
+	if field.Tag != nil {
+		if tag, err := strconv.Unquote(field.Tag.Value); err == nil {
+			jsonTag := extractTag(tag, "json")
+			for _, opt := range strings.Split(jsonTag, ",") {
+				if opt == "omitempty" {
+					return true
+				}
+			}
+		}
+	}
+
+	if field.Doc == nil {
+		return false
+	}
+	for _, c := range field.Doc.List {
+		text := strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), " ")
+		if text == "+optional" || text == "+kubebuilder:validation:Optional" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// underlyingStruct returns the inline struct type of expr, if any.
+func underlyingStruct(expr ast.Expr) (*ast.StructType, bool) {
+	st, ok := expr.(*ast.StructType)
+	return st, ok
+}
+
+// openAPIType maps a Go field type expression to an OpenAPI v3 scalar type.
+// Unrecognised types (named structs from other files, pointers to them,
+// etc.) fall back to "object" since their full shape isn't available from
+// syntax alone.
+func openAPIType(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return "string"
+		case "bool":
+			return "boolean"
+		case "int", "int32", "int64", "uint", "uint32", "uint64":
+			return "integer"
+		case "float32", "float64":
+			return "number"
+		default:
+			return "object"
+		}
+	case *ast.ArrayType:
+		return "array"
+	case *ast.MapType:
+		return "object"
+	case *ast.StarExpr:
+		return openAPIType(t.X)
+	default:
+		return "object"
+	}
+}
+
+// jsonFieldName returns the field's JSON name from its struct tag, and
+// whether it's an inline field: every embedded field, whatever its tag, and
+// any named field whose json tag carries ",inline".
+func jsonFieldName(field *ast.Field) (name string, inline bool) {
+	if len(field.Names) == 0 {
+		return "", true
+	}
+
+	name = field.Names[0].Name
+	if field.Tag == nil {
+		return strings.ToLower(name), false
+	}
+
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return strings.ToLower(name), false
+	}
+
+	jsonTag := extractTag(tag, "json")
+	if jsonTag == "" {
+		return strings.ToLower(name), false
+	}
+
+	parts := strings.Split(jsonTag, ",")
+	if parts[0] == "-" {
+		return "", false
+	}
+	if parts[0] == "" {
+		parts[0] = strings.ToLower(name)
+	}
+
+	for _, opt := range parts[1:] {
+		if opt == "inline" {
+			return parts[0], true
+		}
+	}
+
+	return parts[0], false
+}
+
+// extractTag pulls the value of a single key out of a raw Go struct tag.
+func extractTag(tag, key string) string {
+	for _, part := range strings.Split(tag, " ") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) != 2 || kv[0] != key {
+			continue
+		}
+		v, err := strconv.Unquote(kv[1])
+		if err != nil {
+			continue
+		}
+		return v
+	}
+	return ""
+}
+
+// applyValidationMarkers reads +kubebuilder:validation:* and +listType
+// comment markers from doc and applies the recognised ones to schema.
+// +optional and +kubebuilder:validation:Optional are handled separately, by
+// isOptionalField, since they affect the parent object's Required list
+// rather than this field's own schema.
+func applyValidationMarkers(schema *apiextensionsv1.JSONSchemaProps, doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+
+	for _, c := range doc.List {
+		text := strings.TrimPrefix(strings.TrimPrefix(c.Text, "//"), " ")
+		switch {
+		case strings.HasPrefix(text, "+kubebuilder:validation:Minimum="):
+			if v, ok := parseFloatMarker(text, "+kubebuilder:validation:Minimum="); ok {
+				schema.Minimum = &v
+			}
+		case strings.HasPrefix(text, "+kubebuilder:validation:Maximum="):
+			if v, ok := parseFloatMarker(text, "+kubebuilder:validation:Maximum="); ok {
+				schema.Maximum = &v
+			}
+		case strings.HasPrefix(text, "+kubebuilder:validation:MinLength="):
+			if v, ok := parseIntMarker(text, "+kubebuilder:validation:MinLength="); ok {
+				schema.MinLength = &v
+			}
+		case strings.HasPrefix(text, "+kubebuilder:validation:MaxLength="):
+			if v, ok := parseIntMarker(text, "+kubebuilder:validation:MaxLength="); ok {
+				schema.MaxLength = &v
+			}
+		case strings.HasPrefix(text, "+kubebuilder:validation:Pattern="):
+			schema.Pattern = strings.Trim(strings.TrimPrefix(text, "+kubebuilder:validation:Pattern="), `"`)
+		case strings.HasPrefix(text, "+kubebuilder:validation:Enum="):
+			for _, v := range strings.Split(strings.TrimPrefix(text, "+kubebuilder:validation:Enum="), ";") {
+				schema.Enum = append(schema.Enum, apiextensionsv1.JSON{Raw: []byte(enumLiteral(schema.Type, v))})
+			}
+		case strings.HasPrefix(text, "+listType="):
+			v := strings.Trim(strings.TrimPrefix(text, "+listType="), `"`)
+			schema.XListType = &v
+		}
+	}
+}
+
+// enumLiteral renders a single +kubebuilder:validation:Enum= value as a JSON
+// literal matching the field's underlying type, so e.g. an int field's
+// Enum=1;2;3 produces the integers 1, 2, 3 instead of the strings "1", "2",
+// "3", which wouldn't unify with the field's own `int` base type. Values
+// that don't parse as the declared type fall back to a quoted string.
+func enumLiteral(fieldType, v string) string {
+	switch fieldType {
+	case "integer":
+		if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return v
+		}
+	case "boolean":
+		if v == "true" || v == "false" {
+			return v
+		}
+	}
+	return strconv.Quote(v)
+}
+
+func parseFloatMarker(text, prefix string) (float64, bool) {
+	v, err := strconv.ParseFloat(strings.TrimPrefix(text, prefix), 64)
+	return v, err == nil
+}
+
+func parseIntMarker(text, prefix string) (int64, bool) {
+	v, err := strconv.ParseInt(strings.TrimPrefix(text, prefix), 10, 64)
+	return v, err == nil
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Importer renders CUE definitions from CustomResourceDefinition YAML,
+// lowering each field's OpenAPI v3 validation keywords into CUE constraints
+// via cueConstraint/objectConstraint instead of emitting the unconstrained
+// base type on its own.
+type Importer struct {
+	ctx    *cue.Context
+	header string
+}
+
+// NewImporter returns an Importer that prefixes every generated file with
+// header, typically a `timoni:generate` comment recording how to
+// regenerate it.
+func NewImporter(ctx *cue.Context, header string) *Importer {
+	return &Importer{ctx: ctx, header: header}
+}
+
+// Generate parses crdYAML, a multi-doc stream of CustomResourceDefinition
+// objects, and returns one CUE file per group/kind, keyed by "<group>/<kind>".
+// Every leaf field of each version's structural schema is rendered through
+// cueConstraint/objectConstraint, so the generated definitions enforce the
+// same bounds, patterns, enums and list/object constraints the CRD does.
+func (imp *Importer) Generate(crdYAML []byte) (map[string][]byte, error) {
+	out := make(map[string][]byte)
+
+	for _, doc := range splitYAMLDocs(crdYAML) {
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := yaml.Unmarshal(doc, &crd); err != nil {
+			return nil, fmt.Errorf("parsing CRD failed: %w", err)
+		}
+		if crd.Kind != "CustomResourceDefinition" {
+			continue
+		}
+
+		key := path.Join(crd.Spec.Group, strings.ToLower(crd.Spec.Names.Kind))
+
+		var body strings.Builder
+		for _, v := range crd.Spec.Versions {
+			if v.Schema == nil || v.Schema.OpenAPIV3Schema == nil {
+				continue
+			}
+			fmt.Fprintf(&body, "#%s: %s\n\n", crd.Spec.Names.Kind, renderCUEObject(v.Schema.OpenAPIV3Schema, ""))
+		}
+
+		var b strings.Builder
+		b.WriteString(imp.header)
+		b.WriteString("\n\n")
+		// cueConstraint/formatConstraint emit unqualified references into
+		// these packages (strings.MinRunes, list.MinItems, time.Format, ...);
+		// import only the ones the definitions reference, since CUE rejects
+		// unused imports.
+		var imports []string
+		for _, pkg := range []string{"strings", "list", "time"} {
+			if strings.Contains(body.String(), pkg+".") {
+				imports = append(imports, fmt.Sprintf("\t%q\n", pkg))
+			}
+		}
+		if len(imports) > 0 {
+			fmt.Fprintf(&b, "import (\n%s)\n\n", strings.Join(imports, ""))
+		}
+		b.WriteString(body.String())
+
+		src := b.String()
+		if imp.ctx != nil {
+			if v := imp.ctx.CompileString(src); v.Err() != nil {
+				return nil, fmt.Errorf("generated CUE for %s is invalid: %w", key, v.Err())
+			}
+		}
+
+		out[key] = []byte(src)
+	}
+
+	return out, nil
+}
+
+// splitYAMLDocs splits a multi-doc YAML stream on "---" document separator
+// lines.
+func splitYAMLDocs(data []byte) [][]byte {
+	var docs [][]byte
+	var buf bytes.Buffer
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for sc.Scan() {
+		if strings.TrimSpace(sc.Text()) == "---" {
+			if buf.Len() > 0 {
+				docs = append(docs, append([]byte(nil), buf.Bytes()...))
+				buf.Reset()
+			}
+			continue
+		}
+		buf.WriteString(sc.Text())
+		buf.WriteByte('\n')
+	}
+	if strings.TrimSpace(buf.String()) != "" {
+		docs = append(docs, append([]byte(nil), buf.Bytes()...))
+	}
+
+	return docs
+}
+
+// renderCUEObject renders schema as a CUE struct literal: one field per
+// schema.Properties entry, marked optional unless listed in schema.Required,
+// plus the `...` suffix objectConstraint adds for
+// x-kubernetes-preserve-unknown-fields.
+func renderCUEObject(schema *apiextensionsv1.JSONSchemaProps, indent string) string {
+	if schema == nil || len(schema.Properties) == 0 {
+		return renderCUEField(schema, indent)
+	}
+
+	required := make(map[string]bool, len(schema.Required))
+	for _, r := range schema.Required {
+		required[r] = true
+	}
+
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	inner := indent + "\t"
+	var b strings.Builder
+	b.WriteString("{\n")
+	for _, name := range names {
+		prop := schema.Properties[name]
+		opt := "?"
+		if required[name] {
+			opt = ""
+		}
+		fmt.Fprintf(&b, "%s%s%s: %s\n", inner, name, opt, renderCUEField(&prop, inner))
+	}
+	if suffix := objectConstraint(schema); suffix != "" {
+		fmt.Fprintf(&b, "%s%s\n", inner, suffix)
+	}
+	b.WriteString(indent + "}")
+
+	return b.String()
+}
+
+// renderCUEField renders the CUE type for a single leaf or nested field,
+// conjoining its base type with cueConstraint(schema).
+func renderCUEField(schema *apiextensionsv1.JSONSchemaProps, indent string) string {
+	if schema == nil {
+		return "_"
+	}
+
+	switch schema.Type {
+	case "object":
+		if len(schema.Properties) > 0 {
+			return renderCUEObject(schema, indent)
+		}
+		if schema.AdditionalProperties != nil && schema.AdditionalProperties.Schema != nil {
+			return fmt.Sprintf("{[string]: %s}", renderCUEField(schema.AdditionalProperties.Schema, indent))
+		}
+		return cueConstraint("{...}", schema)
+	case "array":
+		item := "_"
+		if schema.Items != nil && schema.Items.Schema != nil {
+			item = renderCUEField(schema.Items.Schema, indent)
+		}
+		return cueConstraint(fmt.Sprintf("[...%s]", item), schema)
+	case "string":
+		return cueConstraint("string", schema)
+	case "integer":
+		return cueConstraint("int", schema)
+	case "number":
+		return cueConstraint("number", schema)
+	case "boolean":
+		return cueConstraint("bool", schema)
+	default:
+		return cueConstraint("_", schema)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+)
+
+// certManagerCertificateCRD is a condensed version of cert-manager's
+// Certificate CRD: a bounded string (secretName), an enum (privateKey
+// rotationPolicy) and a duration-shaped pattern (renewBefore).
+const certManagerCertificateCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: certificates.cert-manager.io
+spec:
+  group: cert-manager.io
+  names:
+    kind: Certificate
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: ["secretName"]
+            properties:
+              secretName:
+                type: string
+                minLength: 1
+                maxLength: 253
+              renewBefore:
+                type: string
+                pattern: "^([0-9]+(h|m|s))+$"
+              privateKey:
+                type: object
+                properties:
+                  rotationPolicy:
+                    type: string
+                    enum: ["Never", "Always"]
+`
+
+// flagerHelmReleaseCRD is a condensed version of Flux's HelmRelease CRD: a
+// bounded retry count and a reconciliation-interval pattern.
+const flagerHelmReleaseCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: helmreleases.helm.toolkit.fluxcd.io
+spec:
+  group: helm.toolkit.fluxcd.io
+  names:
+    kind: HelmRelease
+  versions:
+  - name: v2
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: ["interval"]
+            properties:
+              interval:
+                type: string
+                pattern: "^([0-9]+(s|m|h))+$"
+              maxHistory:
+                type: integer
+                minimum: 0
+                maximum: 100
+`
+
+// prometheusServiceMonitorCRD is a condensed version of Prometheus
+// Operator's ServiceMonitor CRD: a label-selector pattern and a list of
+// scrape endpoints.
+const prometheusServiceMonitorCRD = `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: servicemonitors.monitoring.coreos.com
+spec:
+  group: monitoring.coreos.com
+  names:
+    kind: ServiceMonitor
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: ["endpoints"]
+            properties:
+              endpoints:
+                type: array
+                minItems: 1
+                items:
+                  type: object
+                  properties:
+                    interval:
+                      type: string
+                      pattern: "^[0-9]+(ms|s|m|h)$"
+`
+
+// TestImporterGenerate feeds condensed fixtures mirroring the validation
+// keywords actually found in the cert-manager, Flux and Prometheus Operator
+// CRDs through Importer.Generate, then compiles the result with the CUE
+// evaluator and checks that a value violating the CRD's constraints is
+// rejected rather than silently accepted.
+func TestImporterGenerate(t *testing.T) {
+	tests := []struct {
+		name       string
+		crd        string
+		defName    string
+		valid      string
+		violating  string
+		violatesOn string
+	}{
+		{
+			name:    "cert-manager Certificate",
+			crd:     certManagerCertificateCRD,
+			defName: "#Certificate",
+			valid: `{
+				spec: {
+					secretName: "my-cert"
+					renewBefore: "360h"
+					privateKey: rotationPolicy: "Always"
+				}
+			}`,
+			violating:  `{spec: {secretName: "my-cert", privateKey: rotationPolicy: "Sometimes"}}`,
+			violatesOn: "enum",
+		},
+		{
+			name:    "Flux HelmRelease",
+			crd:     flagerHelmReleaseCRD,
+			defName: "#HelmRelease",
+			valid: `{
+				spec: {
+					interval: "5m"
+					maxHistory: 10
+				}
+			}`,
+			violating:  `{spec: {interval: "5m", maxHistory: 1000}}`,
+			violatesOn: "maximum",
+		},
+		{
+			name:    "Prometheus Operator ServiceMonitor",
+			crd:     prometheusServiceMonitorCRD,
+			defName: "#ServiceMonitor",
+			valid: `{
+				spec: {
+					endpoints: [{interval: "30s"}]
+				}
+			}`,
+			violating:  `{spec: {endpoints: [{interval: "thirty seconds"}]}}`,
+			violatesOn: "pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := cuecontext.New()
+			imp := NewImporter(ctx, "// Code generated by timoni. DO NOT EDIT.")
+
+			gen, err := imp.Generate([]byte(tt.crd))
+			if err != nil {
+				t.Fatalf("Generate() failed: %v", err)
+			}
+			if len(gen) != 1 {
+				t.Fatalf("Generate() returned %d files, want 1", len(gen))
+			}
+
+			var src []byte
+			for _, v := range gen {
+				src = v
+			}
+			if !strings.Contains(string(src), tt.defName) {
+				t.Fatalf("generated CUE missing %s:\n%s", tt.defName, src)
+			}
+
+			def := ctx.CompileBytes(src)
+			if def.Err() != nil {
+				t.Fatalf("compiling generated CUE failed: %v", def.Err())
+			}
+
+			valid := ctx.CompileString(tt.valid)
+			if valid.Err() != nil {
+				t.Fatalf("compiling valid instance failed: %v", valid.Err())
+			}
+			if err := def.LookupPath(cue.ParsePath(tt.defName)).Unify(valid).Validate(); err != nil {
+				t.Errorf("valid instance rejected: %v", err)
+			}
+
+			violating := ctx.CompileString(tt.violating)
+			if violating.Err() != nil {
+				t.Fatalf("compiling violating instance failed: %v", violating.Err())
+			}
+			if err := def.LookupPath(cue.ParsePath(tt.defName)).Unify(violating).Validate(); err == nil {
+				t.Errorf("violating instance (on %s) was accepted, want rejection", tt.violatesOn)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2023 Stefan Prodan
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CRDVersionInfo describes a single spec.versions[] entry of a
+// CustomResourceDefinition, trimmed down to what the generated #Versions
+// CUE alias needs.
+type CRDVersionInfo struct {
+	Name    string
+	Served  bool
+	Storage bool
+}
+
+// VersionsAlias renders the #Versions CUE alias for a CRD: which of its
+// versions are served, which is the storage version, and its conversion
+// strategy. Module authors use this to branch their CUE definitions on the
+// version they target.
+//
+// versions must be the CRD's full spec.versions, not the subset left after
+// --versions/--storage-only filtering — otherwise #Versions.storage can come
+// up empty when the real storage version was filtered out of the set that's
+// actually rendered to CUE.
+func VersionsAlias(versions []CRDVersionInfo, conversionStrategy string) []byte {
+	var b strings.Builder
+	b.WriteString("// Code generated by timoni. DO NOT EDIT.\n\n")
+	b.WriteString("#Versions: {\n")
+	b.WriteString("\tserved: [")
+	written := 0
+	for _, v := range versions {
+		if !v.Served {
+			continue
+		}
+		if written > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%q", v.Name)
+		written++
+	}
+	b.WriteString("]\n")
+
+	storage := ""
+	for _, v := range versions {
+		if v.Storage {
+			storage = v.Name
+			break
+		}
+	}
+	fmt.Fprintf(&b, "\tstorage: %q\n", storage)
+
+	b.WriteString("\tconversion: strategy: ")
+	fmt.Fprintf(&b, "%q\n", conversionStrategy)
+	b.WriteString("}\n")
+
+	return []byte(b.String())
+}